@@ -0,0 +1,155 @@
+package main
+
+import (
+	"math/bits"
+	"math/rand"
+	"sync"
+)
+
+// Fancy magic bitboards: a perfect-hash alternative to the Hyperbola
+// Quintessence sliding-attack generator above. For each square we
+// precompute the "relevant occupancy" mask (the rook/bishop rays with
+// board-edge squares trimmed off, since a blocker on the edge can never
+// change the attack set - the ray always stops there anyway), then find a
+// magic multiplier that maps every subset of that mask to a unique index
+// into a per-square attack table.
+//
+// rookAttacksMagic/bishopAttacksMagic have the same signature as their HQ
+// counterparts and are selected via the rookAttacks/bishopAttacks function
+// variables in select_hq.go / select_magic.go, switched with the "magic"
+// build tag. Table construction itself is lazy (see magicTablesOnce) so
+// the default HQ build, which never calls these functions, doesn't pay
+// for it at startup.
+
+type magicEntry struct {
+	mask    uint64
+	magic   uint64
+	shift   uint
+	attacks []uint64
+}
+
+var rookMagics [64]magicEntry
+var bishopMagics [64]magicEntry
+
+func rookRelevantMask(sq int) uint64 {
+	r, f := sq/8, sq%8
+	var m uint64
+	for rr := r + 1; rr <= 6; rr++ {
+		m |= 1 << uint(rr*8+f)
+	}
+	for rr := r - 1; rr >= 1; rr-- {
+		m |= 1 << uint(rr*8+f)
+	}
+	for ff := f + 1; ff <= 6; ff++ {
+		m |= 1 << uint(r*8+ff)
+	}
+	for ff := f - 1; ff >= 1; ff-- {
+		m |= 1 << uint(r*8+ff)
+	}
+	return m
+}
+
+func bishopRelevantMask(sq int) uint64 {
+	r, f := sq/8, sq%8
+	var m uint64
+	for rr, ff := r+1, f+1; rr <= 6 && ff <= 6; rr, ff = rr+1, ff+1 {
+		m |= 1 << uint(rr*8+ff)
+	}
+	for rr, ff := r+1, f-1; rr <= 6 && ff >= 1; rr, ff = rr+1, ff-1 {
+		m |= 1 << uint(rr*8+ff)
+	}
+	for rr, ff := r-1, f+1; rr >= 1 && ff <= 6; rr, ff = rr-1, ff+1 {
+		m |= 1 << uint(rr*8+ff)
+	}
+	for rr, ff := r-1, f-1; rr >= 1 && ff >= 1; rr, ff = rr-1, ff-1 {
+		m |= 1 << uint(rr*8+ff)
+	}
+	return m
+}
+
+// subsetsOf enumerates every subset of mask via the Carry-Rippler trick,
+// calling fn once per subset (including the empty subset).
+func subsetsOf(mask uint64, fn func(subset uint64)) {
+	subset := uint64(0)
+	for {
+		fn(subset)
+		subset = (subset - mask) & mask
+		if subset == 0 {
+			break
+		}
+	}
+}
+
+// findMagic searches for a magic multiplier that perfectly hashes every
+// subset of mask to a distinct attacks[] slot, keyed by the true slider
+// attacks (computed with the existing HQ generator, which is already
+// known-correct) for that subset.
+func findMagic(sq int, mask uint64, trueAttacks func(sq int, occ uint64) uint64, rng *rand.Rand) magicEntry {
+	bitsInMask := bits.OnesCount64(mask)
+	shift := uint(64 - bitsInMask)
+	size := 1 << uint(bitsInMask)
+
+	var occs, refs []uint64
+	subsetsOf(mask, func(subset uint64) {
+		occs = append(occs, subset)
+		refs = append(refs, trueAttacks(sq, subset))
+	})
+
+	table := make([]uint64, size)
+	for attempt := 0; ; attempt++ {
+		magic := rng.Uint64() & rng.Uint64() & rng.Uint64()
+		if bits.OnesCount64((mask*magic)&0xFF00000000000000) < 6 {
+			continue // too few high bits set tends to hash poorly
+		}
+		for i := range table {
+			table[i] = 0
+		}
+
+		ok := true
+		used := make([]bool, size)
+		for i, occ := range occs {
+			idx := (occ * magic) >> shift
+			if used[idx] && table[idx] != refs[i] {
+				ok = false
+				break
+			}
+			used[idx] = true
+			table[idx] = refs[i]
+		}
+		if ok {
+			return magicEntry{mask: mask, magic: magic, shift: shift, attacks: table}
+		}
+	}
+}
+
+// magicTablesOnce guards building rookMagics/bishopMagics. The search is
+// ~1s of work across all 128 squares, so it runs lazily on first use
+// instead of in an init(), to keep startup cheap for the default (HQ)
+// build, which never calls rookAttacksMagic/bishopAttacksMagic at all.
+var magicTablesOnce sync.Once
+
+func buildMagicTables() {
+	rng := rand.New(rand.NewSource(1))
+	for sq := 0; sq < 64; sq++ {
+		rookMagics[sq] = findMagic(sq, rookRelevantMask(sq), rookAttacksHQ, rng)
+		bishopMagics[sq] = findMagic(sq, bishopRelevantMask(sq), bishopAttacksHQ, rng)
+	}
+}
+
+func rookAttacksMagic(sq int, occ uint64) uint64 {
+	magicTablesOnce.Do(buildMagicTables)
+	e := &rookMagics[sq]
+	idx := ((occ & e.mask) * e.magic) >> e.shift
+	return e.attacks[idx]
+}
+
+func bishopAttacksMagic(sq int, occ uint64) uint64 {
+	magicTablesOnce.Do(buildMagicTables)
+	e := &bishopMagics[sq]
+	idx := ((occ & e.mask) * e.magic) >> e.shift
+	return e.attacks[idx]
+}
+
+func queenAttacksMagic(sq int, occ uint64) uint64 {
+	return rookAttacksMagic(sq, occ) | bishopAttacksMagic(sq, occ)
+}
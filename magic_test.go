@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+// benchOccupancies is a small, realistic mix of occupancies to benchmark
+// sliding-attack generation against: the starting position, a busy
+// middlegame (Kiwipete), and a sparse endgame.
+func benchOccupancies(b *testing.B) []uint64 {
+	fens := []string{
+		startFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+	occs := make([]uint64, len(fens))
+	for i, fen := range fens {
+		pos, err := parseFEN(fen)
+		if err != nil {
+			b.Fatalf("parsing benchmark FEN %q: %v", fen, err)
+		}
+		occs[i] = pos.All()
+	}
+	return occs
+}
+
+func BenchmarkRookAttacksHQ(b *testing.B) {
+	occs := benchOccupancies(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, occ := range occs {
+			for sq := 0; sq < 64; sq++ {
+				_ = rookAttacksHQ(sq, occ)
+			}
+		}
+	}
+}
+
+func BenchmarkRookAttacksMagic(b *testing.B) {
+	occs := benchOccupancies(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, occ := range occs {
+			for sq := 0; sq < 64; sq++ {
+				_ = rookAttacksMagic(sq, occ)
+			}
+		}
+	}
+}
+
+func BenchmarkBishopAttacksHQ(b *testing.B) {
+	occs := benchOccupancies(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, occ := range occs {
+			for sq := 0; sq < 64; sq++ {
+				_ = bishopAttacksHQ(sq, occ)
+			}
+		}
+	}
+}
+
+func BenchmarkBishopAttacksMagic(b *testing.B) {
+	occs := benchOccupancies(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, occ := range occs {
+			for sq := 0; sq < 64; sq++ {
+				_ = bishopAttacksMagic(sq, occ)
+			}
+		}
+	}
+}
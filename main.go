@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/bits"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -34,6 +35,18 @@ const (
 type Position struct {
 	whitePawns, whiteKnights, whiteBishops, whiteRooks, whiteQueens, whiteKing uint64
 	blackPawns, blackKnights, blackBishops, blackRooks, blackQueens, blackKing uint64
+
+	sideToMove Color
+
+	castleWK, castleWQ bool
+	castleBK, castleBQ bool
+
+	// epSquare is the en-passant target square (the square a capturing pawn
+	// would move to), or -1 if none is available.
+	epSquare int
+
+	halfmoveClock  int
+	fullmoveNumber int
 }
 
 func (p Position) White() uint64 {
@@ -44,6 +57,25 @@ func (p Position) Black() uint64 {
 }
 func (p Position) All() uint64 { return p.White() | p.Black() }
 
+func (p Position) SideToMove() Color { return p.sideToMove }
+
+// CastleRights reports the four castling-availability flags parsed from FEN
+// (they say nothing about whether castling is legal right now).
+func (p Position) CastleRights() (whiteKing, whiteQueen, blackKing, blackQueen bool) {
+	return p.castleWK, p.castleWQ, p.castleBK, p.castleBQ
+}
+
+// EnPassantSquare returns the en-passant target square and whether one is set.
+func (p Position) EnPassantSquare() (int, bool) {
+	if p.epSquare < 0 {
+		return 0, false
+	}
+	return p.epSquare, true
+}
+
+func (p Position) HalfmoveClock() int  { return p.halfmoveClock }
+func (p Position) FullmoveNumber() int { return p.fullmoveNumber }
+
 func (p Position) KingSquare(c Color) (int, bool) {
 	var bb uint64
 	if c == White {
@@ -60,7 +92,6 @@ func (p Position) KingSquare(c Color) (int, bool) {
 // ---------------------- FEN parsing ------------------------
 
 func parseFEN(fen string) (Position, error) {
-	// Accept full FEN but only piece placement is needed for check.
 	parts := strings.Fields(fen)
 	if len(parts) == 0 {
 		return Position{}, fmt.Errorf("empty FEN")
@@ -96,9 +127,185 @@ func parseFEN(fen string) (Position, error) {
 			return Position{}, fmt.Errorf("incomplete rank at rank %d", 8-r)
 		}
 	}
+
+	// Remaining fields: active color, castling availability, en-passant
+	// target, halfmove clock, fullmove number. All but the board are
+	// optional so older callers passing a bare board still parse.
+	pos.sideToMove = White
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "w":
+			pos.sideToMove = White
+		case "b":
+			pos.sideToMove = Black
+		default:
+			return Position{}, fmt.Errorf("invalid active color %q", parts[1])
+		}
+	}
+
+	if len(parts) > 2 && parts[2] != "-" {
+		for _, ch := range parts[2] {
+			switch ch {
+			case 'K':
+				pos.castleWK = true
+			case 'Q':
+				pos.castleWQ = true
+			case 'k':
+				pos.castleBK = true
+			case 'q':
+				pos.castleBQ = true
+			default:
+				return Position{}, fmt.Errorf("invalid castling field %q", parts[2])
+			}
+		}
+	}
+
+	pos.epSquare = -1
+	if len(parts) > 3 && parts[3] != "-" {
+		sq, ok := squareFromAlgebraic(parts[3])
+		if !ok {
+			return Position{}, fmt.Errorf("invalid en-passant square %q", parts[3])
+		}
+		pos.epSquare = sq
+	}
+
+	pos.halfmoveClock = 0
+	if len(parts) > 4 {
+		n, err := strconv.Atoi(parts[4])
+		if err != nil {
+			return Position{}, fmt.Errorf("invalid halfmove clock %q", parts[4])
+		}
+		pos.halfmoveClock = n
+	}
+
+	pos.fullmoveNumber = 1
+	if len(parts) > 5 {
+		n, err := strconv.Atoi(parts[5])
+		if err != nil {
+			return Position{}, fmt.Errorf("invalid fullmove number %q", parts[5])
+		}
+		pos.fullmoveNumber = n
+	}
+
 	return pos, nil
 }
 
+// FEN renders pos as a Forsyth-Edwards Notation string, the inverse of
+// parseFEN.
+func (p Position) FEN() string {
+	var sb strings.Builder
+	for r := 7; r >= 0; r-- {
+		empty := 0
+		for f := 0; f < 8; f++ {
+			sq := r*8 + f
+			ch := pieceCharAt(p, sq)
+			if ch == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteRune(ch)
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if r > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	sb.WriteByte(' ')
+	if p.sideToMove == White {
+		sb.WriteByte('w')
+	} else {
+		sb.WriteByte('b')
+	}
+
+	sb.WriteByte(' ')
+	castling := ""
+	if p.castleWK {
+		castling += "K"
+	}
+	if p.castleWQ {
+		castling += "Q"
+	}
+	if p.castleBK {
+		castling += "k"
+	}
+	if p.castleBQ {
+		castling += "q"
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	sb.WriteByte(' ')
+	if ep, ok := p.EnPassantSquare(); ok {
+		sb.WriteString(squareName(ep))
+	} else {
+		sb.WriteByte('-')
+	}
+
+	fmt.Fprintf(&sb, " %d %d", p.halfmoveClock, p.fullmoveNumber)
+	return sb.String()
+}
+
+func pieceCharAt(p Position, sq int) rune {
+	bit := uint64(1) << uint(sq)
+	switch {
+	case p.whitePawns&bit != 0:
+		return 'P'
+	case p.whiteKnights&bit != 0:
+		return 'N'
+	case p.whiteBishops&bit != 0:
+		return 'B'
+	case p.whiteRooks&bit != 0:
+		return 'R'
+	case p.whiteQueens&bit != 0:
+		return 'Q'
+	case p.whiteKing&bit != 0:
+		return 'K'
+	case p.blackPawns&bit != 0:
+		return 'p'
+	case p.blackKnights&bit != 0:
+		return 'n'
+	case p.blackBishops&bit != 0:
+		return 'b'
+	case p.blackRooks&bit != 0:
+		return 'r'
+	case p.blackQueens&bit != 0:
+		return 'q'
+	case p.blackKing&bit != 0:
+		return 'k'
+	default:
+		return 0
+	}
+}
+
+// squareFromAlgebraic parses a square like "e3" into its 0..63 index.
+func squareFromAlgebraic(s string) (int, bool) {
+	if len(s) != 2 {
+		return 0, false
+	}
+	file := s[0]
+	rank := s[1]
+	if file < 'a' || file > 'h' || rank < '1' || rank > '8' {
+		return 0, false
+	}
+	return int(rank-'1')*8 + int(file-'a'), true
+}
+
+// squareName renders a 0..63 index as algebraic notation, e.g. "e4".
+func squareName(sq int) string {
+	file := sq % 8
+	rank := sq / 8
+	return string([]byte{byte('a' + file), byte('1' + rank)})
+}
+
 func setPieceAt(p *Position, ch rune, sq int) {
 	b := uint64(1) << uint(sq)
 	switch ch {
@@ -318,11 +525,11 @@ func IsSquareAttacked(pos *Position, sq int, c Color) bool {
 	// Sliding pieces
 	// Generate rays from the target square and see if the first blocker is a slider of color c.
 	bbBishQueens := bishopBB(pos, c) | queenBB(pos, c)
-	if (bishopAttacksHQ(sq, occ) & bbBishQueens) != 0 {
+	if (bishopAttacks(sq, occ) & bbBishQueens) != 0 {
 		return true
 	}
 	bbRookQueens := rookBB(pos, c) | queenBB(pos, c)
-	if (rookAttacksHQ(sq, occ) & bbRookQueens) != 0 {
+	if (rookAttacks(sq, occ) & bbRookQueens) != 0 {
 		return true
 	}
 	return false
@@ -482,6 +689,19 @@ func selectPredefinedFEN(in *bufio.Reader) (string, bool) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "uci" {
+		runUCI(os.Stdin, os.Stdout)
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "pgn" {
+		runPGNAnalysis(os.Args[2])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "perft" {
+		runPerft(os.Args[2:])
+		return
+	}
+
 	in := bufio.NewReader(os.Stdin)
 
 	for {
@@ -0,0 +1,698 @@
+package main
+
+import "math/bits"
+
+// ---------------------- Move representation ------------------------
+
+type PieceType int
+
+const (
+	NoPiece PieceType = iota
+	Pawn
+	Knight
+	Bishop
+	Rook
+	Queen
+	King
+)
+
+type MoveKind int
+
+const (
+	Quiet MoveKind = iota
+	DoublePawnPush
+	Capture
+	EnPassantCapture
+	CastleKingside
+	CastleQueenside
+	PromoKnight
+	PromoBishop
+	PromoRook
+	PromoQueen
+	PromoCaptureKnight
+	PromoCaptureBishop
+	PromoCaptureRook
+	PromoCaptureQueen
+)
+
+// Move is a single legal or pseudo-legal move. Promotion moves carry their
+// target piece in Kind; everything else needed to apply the move (which
+// piece moved, what if anything was captured) can be recovered from the
+// Position the move was generated against.
+type Move struct {
+	From, To int
+	Kind     MoveKind
+}
+
+func (k MoveKind) isPromotion() bool {
+	switch k {
+	case PromoKnight, PromoBishop, PromoRook, PromoQueen,
+		PromoCaptureKnight, PromoCaptureBishop, PromoCaptureRook, PromoCaptureQueen:
+		return true
+	}
+	return false
+}
+
+func (k MoveKind) isCapture() bool {
+	switch k {
+	case Capture, EnPassantCapture, PromoCaptureKnight, PromoCaptureBishop, PromoCaptureRook, PromoCaptureQueen:
+		return true
+	}
+	return false
+}
+
+func (k MoveKind) promotionPiece() (rune, bool) {
+	switch k {
+	case PromoKnight, PromoCaptureKnight:
+		return 'n', true
+	case PromoBishop, PromoCaptureBishop:
+		return 'b', true
+	case PromoRook, PromoCaptureRook:
+		return 'r', true
+	case PromoQueen, PromoCaptureQueen:
+		return 'q', true
+	}
+	return 0, false
+}
+
+// String renders a move in long algebraic notation ("e2e4", "e7e8q"), the
+// form UCI engines speak.
+func (m Move) String() string {
+	s := squareName(m.From) + squareName(m.To)
+	if p, ok := m.Kind.promotionPiece(); ok {
+		s += string(p)
+	}
+	return s
+}
+
+// ---------------------- Ray tables ------------------------
+
+// Direction indices into rays/raySteps.
+const (
+	dirN = iota
+	dirS
+	dirE
+	dirW
+	dirNE
+	dirNW
+	dirSE
+	dirSW
+)
+
+var rayStepDR = [8]int{1, -1, 0, 0, 1, 1, -1, -1}
+var rayStepDF = [8]int{0, 0, 1, -1, 1, -1, 1, -1}
+
+// rays[dir][sq] is the set of all squares reachable from sq by repeatedly
+// stepping in direction dir, not including sq itself. Unlike lineAttacksHQ
+// these are unblocked, single-direction rays, which is what pin and
+// checker detection need to walk outward from a king square.
+var rays [8][64]uint64
+
+func init() {
+	for dir := 0; dir < 8; dir++ {
+		for sq := 0; sq < 64; sq++ {
+			r, f := sq/8, sq%8
+			var m uint64
+			rr, ff := r+rayStepDR[dir], f+rayStepDF[dir]
+			for onBoard(rr, ff) {
+				m |= 1 << uint(rr*8+ff)
+				rr += rayStepDR[dir]
+				ff += rayStepDF[dir]
+			}
+			rays[dir][sq] = m
+		}
+	}
+}
+
+var rookDirs = [4]int{dirN, dirS, dirE, dirW}
+var bishopDirs = [4]int{dirNE, dirNW, dirSE, dirSW}
+
+// nearestInRay returns the closest occupied square to sq along direction
+// dir, or -1 if the ray is empty of blockers.
+func nearestInRay(dir, sq int, occ uint64) int {
+	blockers := rays[dir][sq] & occ
+	if blockers == 0 {
+		return -1
+	}
+	switch dir {
+	case dirN, dirE, dirNE, dirNW:
+		return bits.TrailingZeros64(blockers)
+	default:
+		return 63 - bits.LeadingZeros64(blockers)
+	}
+}
+
+// between returns the squares strictly between a and b if they lie on a
+// shared rank, file or diagonal; otherwise 0.
+func between(a, b int) uint64 {
+	for dir := 0; dir < 8; dir++ {
+		if rays[dir][a]&(uint64(1)<<uint(b)) == 0 {
+			continue
+		}
+		// Squares on the ray from a up to (excluding) b.
+		beyond := rays[dir][b] | (uint64(1) << uint(b))
+		return rays[dir][a] &^ beyond
+	}
+	return 0
+}
+
+// ---------------------- Attackers / checkers / pins ------------------------
+
+// attackersTo returns the bitboard of "by"-colored pieces attacking sq,
+// given occupancy occ (callers may strip a square out of occ, e.g. the
+// king, to compute x-ray danger).
+func attackersTo(pos *Position, sq int, by Color, occ uint64) uint64 {
+	var attackers uint64
+
+	// A white pawn attacks sq iff it sits where a black pawn on sq would
+	// attack from (and vice versa) - the attack relation is symmetric.
+	if by == White {
+		attackers |= pos.whitePawns & pawnAttacksBlackFrom[sq]
+	} else {
+		attackers |= pos.blackPawns & pawnAttacksWhiteFrom[sq]
+	}
+
+	attackers |= knightAttacks[sq] & knightBB(pos, by)
+	attackers |= kingAttacks[sq] & kingBB(pos, by)
+	attackers |= bishopAttacks(sq, occ) & (bishopBB(pos, by) | queenBB(pos, by))
+	attackers |= rookAttacks(sq, occ) & (rookBB(pos, by) | queenBB(pos, by))
+	return attackers
+}
+
+// pinInfo describes one absolutely pinned piece: it may only move along
+// allowed (a ray through the king and the pinning slider, inclusive of
+// capturing the pinner).
+type pinInfo struct {
+	sq      int
+	allowed uint64
+}
+
+// pinnedPieces finds pieces of color c that are absolutely pinned to their
+// own king: a single own piece standing between the king and an enemy
+// slider of matching direction, on a rank, file or diagonal.
+func pinnedPieces(pos *Position, c Color) []pinInfo {
+	kingSq, ok := pos.KingSquare(c)
+	if !ok {
+		return nil
+	}
+	occ := pos.All()
+	enemyRookQueen := rookBB(pos, oppositeColor(c)) | queenBB(pos, oppositeColor(c))
+	enemyBishopQueen := bishopBB(pos, oppositeColor(c)) | queenBB(pos, oppositeColor(c))
+	var own uint64
+	if c == White {
+		own = pos.White()
+	} else {
+		own = pos.Black()
+	}
+
+	var pins []pinInfo
+	scan := func(dirs [4]int, enemySliders uint64) {
+		for _, dir := range dirs {
+			first := nearestInRay(dir, kingSq, occ)
+			if first < 0 || (uint64(1)<<uint(first))&own == 0 {
+				continue // empty ray, or first blocker is an enemy piece (not a pin candidate)
+			}
+			occWithoutFirst := occ &^ (uint64(1) << uint(first))
+			second := nearestInRay(dir, kingSq, occWithoutFirst)
+			if second < 0 {
+				continue
+			}
+			if (uint64(1)<<uint(second))&enemySliders == 0 {
+				continue
+			}
+			pins = append(pins, pinInfo{
+				sq:      first,
+				allowed: between(kingSq, second) | (uint64(1) << uint(second)),
+			})
+		}
+	}
+	scan(rookDirs, enemyRookQueen)
+	scan(bishopDirs, enemyBishopQueen)
+	return pins
+}
+
+// Checkers returns the bitboard of opposing pieces currently giving check
+// to c's king (empty if c is not in check, or c has no king on board).
+func (p Position) Checkers(c Color) uint64 {
+	kingSq, ok := p.KingSquare(c)
+	if !ok {
+		return 0
+	}
+	return attackersTo(&p, kingSq, oppositeColor(c), p.All())
+}
+
+// Pinned returns the bitboard of c's own pieces that are absolutely
+// pinned to c's king: a piece lying alone on a rank, file or diagonal
+// between the king and an enemy slider of matching direction. A pinned
+// piece may only move along the ray connecting the king to the pinner.
+func (p Position) Pinned(c Color) uint64 {
+	var bb uint64
+	for _, pin := range pinnedPieces(&p, c) {
+		bb |= uint64(1) << uint(pin.sq)
+	}
+	return bb
+}
+
+func oppositeColor(c Color) Color {
+	if c == White {
+		return Black
+	}
+	return White
+}
+
+// ---------------------- Move generation ------------------------
+
+// LegalMoves returns every legal move for side to move in pos, accounting
+// for pins and check. With no checker the full pseudo-legal set (minus
+// pinned pieces moving off their pin ray) is legal; under single check,
+// non-king moves must capture the checker or block the checking ray;
+// under double check only king moves are legal.
+func LegalMoves(pos *Position, side Color) []Move {
+	kingSq, ok := pos.KingSquare(side)
+	if !ok {
+		return nil
+	}
+	enemy := oppositeColor(side)
+
+	checkers := pos.Checkers(side)
+	numCheckers := bits.OnesCount64(checkers)
+
+	moves := generateKingMoves(pos, side)
+	moves = append(moves, generateCastlingMoves(pos, side)...)
+	if numCheckers < 2 {
+		moves = append(moves, generatePawnMoves(pos, side)...)
+		moves = append(moves, generatePieceMoves(pos, side, Knight)...)
+		moves = append(moves, generatePieceMoves(pos, side, Bishop)...)
+		moves = append(moves, generatePieceMoves(pos, side, Rook)...)
+		moves = append(moves, generatePieceMoves(pos, side, Queen)...)
+	}
+
+	var checkMask uint64 = ^uint64(0)
+	if numCheckers == 1 {
+		checkerSq := bits.TrailingZeros64(checkers)
+		checkMask = between(kingSq, checkerSq) | checkers
+	}
+
+	pins := pinnedPieces(pos, side)
+	pinAllowed := make(map[int]uint64, len(pins))
+	for _, pin := range pins {
+		pinAllowed[pin.sq] = pin.allowed
+	}
+
+	legal := make([]Move, 0, len(moves))
+	for _, m := range moves {
+		if m.Kind == CastleKingside || m.Kind == CastleQueenside {
+			legal = append(legal, m) // generateCastlingMoves already validated safety
+			continue
+		}
+		if m.From == kingSq {
+			if !squareAttackedIgnoringKing(pos, m.To, enemy, kingSq) {
+				legal = append(legal, m)
+			}
+			continue
+		}
+		if numCheckers > 0 && m.Kind == EnPassantCapture {
+			// The captured pawn's square, not the destination square, is
+			// what must match the checker: EP can't block a sliding
+			// check, only capture a pawn that's giving check itself.
+			if checkMask&(uint64(1)<<uint(enPassantCaptureSquare(side, m.To))) == 0 {
+				continue
+			}
+		} else if numCheckers > 0 && (checkMask&(uint64(1)<<uint(m.To))) == 0 {
+			continue
+		}
+		if allowed, isPinned := pinAllowed[m.From]; isPinned && allowed&(uint64(1)<<uint(m.To)) == 0 {
+			continue
+		}
+		if m.Kind == EnPassantCapture && !enPassantIsSafe(pos, side, m) {
+			continue
+		}
+		legal = append(legal, m)
+	}
+	return legal
+}
+
+// squareAttackedIgnoringKing checks whether dest is attacked by "by",
+// treating the moving king as absent from occupancy so sliding attacks
+// correctly x-ray through the square the king is vacating.
+func squareAttackedIgnoringKing(pos *Position, dest int, by Color, kingSq int) bool {
+	occ := pos.All() &^ (uint64(1) << uint(kingSq))
+	return attackersTo(pos, dest, by, occ) != 0
+}
+
+// enPassantIsSafe re-validates an en-passant capture by simulating it: the
+// classic horizontal pin (two pawns either side of the king, captured and
+// capturer both removed from the rank in one move) isn't caught by the
+// ordinary pin or check-mask logic above.
+func enPassantIsSafe(pos *Position, side Color, m Move) bool {
+	next := ApplyMove(*pos, m)
+	kingSq, ok := next.KingSquare(side)
+	if !ok {
+		return true
+	}
+	return attackersTo(&next, kingSq, oppositeColor(side), next.All()) == 0
+}
+
+func pawnBB(p *Position, c Color) uint64 {
+	if c == White {
+		return p.whitePawns
+	}
+	return p.blackPawns
+}
+
+func generatePawnMoves(pos *Position, side Color) []Move {
+	var moves []Move
+	occ := pos.All()
+	var enemy uint64
+	var forward, startRank, promoRank int
+	if side == White {
+		enemy = pos.Black()
+		forward, startRank, promoRank = 8, 1, 7
+	} else {
+		enemy = pos.White()
+		forward, startRank, promoRank = -8, 6, 0
+	}
+
+	pawns := pawnBB(pos, side)
+	for bb := pawns; bb != 0; bb &= bb - 1 {
+		from := bits.TrailingZeros64(bb)
+		rank := from / 8
+
+		one := from + forward
+		if one >= 0 && one < 64 && occ&(uint64(1)<<uint(one)) == 0 {
+			addPawnMove(&moves, from, one, promoRank)
+			two := from + 2*forward
+			if rank == startRank && occ&(uint64(1)<<uint(two)) == 0 {
+				moves = append(moves, Move{From: from, To: two, Kind: DoublePawnPush})
+			}
+		}
+
+		for _, to := range pawnCaptureSquares(from, side) {
+			toBit := uint64(1) << uint(to)
+			if enemy&toBit != 0 {
+				addPawnCapture(&moves, from, to, promoRank)
+			} else if ep, ok := pos.EnPassantSquare(); ok && to == ep {
+				moves = append(moves, Move{From: from, To: to, Kind: EnPassantCapture})
+			}
+		}
+	}
+	return moves
+}
+
+func pawnCaptureSquares(from int, side Color) []int {
+	if side == White {
+		return bitsToSquares(pawnAttacksWhiteFrom[from])
+	}
+	return bitsToSquares(pawnAttacksBlackFrom[from])
+}
+
+func bitsToSquares(bb uint64) []int {
+	var sqs []int
+	for ; bb != 0; bb &= bb - 1 {
+		sqs = append(sqs, bits.TrailingZeros64(bb))
+	}
+	return sqs
+}
+
+func addPawnMove(moves *[]Move, from, to, promoRank int) {
+	if to/8 == promoRank {
+		*moves = append(*moves,
+			Move{From: from, To: to, Kind: PromoQueen},
+			Move{From: from, To: to, Kind: PromoRook},
+			Move{From: from, To: to, Kind: PromoBishop},
+			Move{From: from, To: to, Kind: PromoKnight},
+		)
+		return
+	}
+	*moves = append(*moves, Move{From: from, To: to, Kind: Quiet})
+}
+
+func addPawnCapture(moves *[]Move, from, to, promoRank int) {
+	if to/8 == promoRank {
+		*moves = append(*moves,
+			Move{From: from, To: to, Kind: PromoCaptureQueen},
+			Move{From: from, To: to, Kind: PromoCaptureRook},
+			Move{From: from, To: to, Kind: PromoCaptureBishop},
+			Move{From: from, To: to, Kind: PromoCaptureKnight},
+		)
+		return
+	}
+	*moves = append(*moves, Move{From: from, To: to, Kind: Capture})
+}
+
+func generatePieceMoves(pos *Position, side Color, piece PieceType) []Move {
+	var bb uint64
+	switch piece {
+	case Knight:
+		bb = knightBB(pos, side)
+	case Bishop:
+		bb = bishopBB(pos, side)
+	case Rook:
+		bb = rookBB(pos, side)
+	case Queen:
+		bb = queenBB(pos, side)
+	}
+	occ := pos.All()
+	var own uint64
+	if side == White {
+		own = pos.White()
+	} else {
+		own = pos.Black()
+	}
+
+	var moves []Move
+	for ; bb != 0; bb &= bb - 1 {
+		from := bits.TrailingZeros64(bb)
+		var attacks uint64
+		switch piece {
+		case Knight:
+			attacks = knightAttacks[from]
+		case Bishop:
+			attacks = bishopAttacks(from, occ)
+		case Rook:
+			attacks = rookAttacks(from, occ)
+		case Queen:
+			attacks = queenAttacks(from, occ)
+		}
+		attacks &^= own
+		for dests := attacks; dests != 0; dests &= dests - 1 {
+			to := bits.TrailingZeros64(dests)
+			kind := Quiet
+			if occ&(uint64(1)<<uint(to)) != 0 {
+				kind = Capture
+			}
+			moves = append(moves, Move{From: from, To: to, Kind: kind})
+		}
+	}
+	return moves
+}
+
+func generateKingMoves(pos *Position, side Color) []Move {
+	kingSq, ok := pos.KingSquare(side)
+	if !ok {
+		return nil
+	}
+	var own, occ uint64
+	occ = pos.All()
+	if side == White {
+		own = pos.White()
+	} else {
+		own = pos.Black()
+	}
+	attacks := kingAttacks[kingSq] &^ own
+
+	var moves []Move
+	for dests := attacks; dests != 0; dests &= dests - 1 {
+		to := bits.TrailingZeros64(dests)
+		kind := Quiet
+		if occ&(uint64(1)<<uint(to)) != 0 {
+			kind = Capture
+		}
+		moves = append(moves, Move{From: kingSq, To: to, Kind: kind})
+	}
+	return moves
+}
+
+// generateCastlingMoves produces castling moves already validated for
+// rights, empty intervening squares, and that the king is not currently
+// in check, does not pass through, and does not land on an attacked
+// square.
+func generateCastlingMoves(pos *Position, side Color) []Move {
+	kingSq, ok := pos.KingSquare(side)
+	if !ok {
+		return nil
+	}
+	enemy := oppositeColor(side)
+	occ := pos.All()
+	wk, wq, bk, bq := pos.CastleRights()
+
+	var moves []Move
+	attacked := func(sq int) bool { return attackersTo(pos, sq, enemy, occ) != 0 }
+
+	if side == White {
+		if wk && occ&((uint64(1)<<F1)|(uint64(1)<<G1)) == 0 &&
+			!attacked(E1) && !attacked(F1) && !attacked(G1) {
+			moves = append(moves, Move{From: kingSq, To: G1, Kind: CastleKingside})
+		}
+		if wq && occ&((uint64(1)<<D1)|(uint64(1)<<C1)|(uint64(1)<<B1)) == 0 &&
+			!attacked(E1) && !attacked(D1) && !attacked(C1) {
+			moves = append(moves, Move{From: kingSq, To: C1, Kind: CastleQueenside})
+		}
+	} else {
+		if bk && occ&((uint64(1)<<F8)|(uint64(1)<<G8)) == 0 &&
+			!attacked(E8) && !attacked(F8) && !attacked(G8) {
+			moves = append(moves, Move{From: kingSq, To: G8, Kind: CastleKingside})
+		}
+		if bq && occ&((uint64(1)<<D8)|(uint64(1)<<C8)|(uint64(1)<<B8)) == 0 &&
+			!attacked(E8) && !attacked(D8) && !attacked(C8) {
+			moves = append(moves, Move{From: kingSq, To: C8, Kind: CastleQueenside})
+		}
+	}
+	return moves
+}
+
+// enPassantCaptureSquare returns the square of the pawn removed by an
+// en-passant capture landing on dest, for the side making the capture.
+func enPassantCaptureSquare(side Color, dest int) int {
+	if side == White {
+		return dest - 8
+	}
+	return dest + 8
+}
+
+// ---------------------- Applying moves ------------------------
+
+// ApplyMove returns the position after playing m, updating side to move,
+// castling rights, the en-passant square, and the move counters. It does
+// not validate legality; callers should only apply moves LegalMoves
+// produced (or ones they've otherwise checked).
+func ApplyMove(pos Position, m Move) Position {
+	side := pos.sideToMove
+	enemy := oppositeColor(side)
+	fromBit := uint64(1) << uint(m.From)
+
+	movingPawn := pawnBB(&pos, side)&fromBit != 0
+
+	if m.Kind.isCapture() {
+		captureSq := m.To
+		if m.Kind == EnPassantCapture {
+			captureSq = enPassantCaptureSquare(side, m.To)
+		}
+		clearSquare(&pos, enemy, captureSq)
+	}
+
+	movePiece(&pos, side, m.From, m.To)
+
+	if p, ok := m.Kind.promotionPiece(); ok {
+		clearSquare(&pos, side, m.To) // remove the pawn placed on the promotion square
+		setPieceAt(&pos, promotionRune(p, side), m.To)
+	}
+
+	if m.Kind == CastleKingside || m.Kind == CastleQueenside {
+		rank := 0
+		if side == Black {
+			rank = 7
+		}
+		if m.Kind == CastleKingside {
+			movePiece(&pos, side, rank*8+7, rank*8+5)
+		} else {
+			movePiece(&pos, side, rank*8+0, rank*8+3)
+		}
+	}
+
+	pos.epSquare = -1
+	if m.Kind == DoublePawnPush {
+		pos.epSquare = (m.From + m.To) / 2
+	}
+
+	updateCastlingRights(&pos, m.From)
+	updateCastlingRights(&pos, m.To)
+
+	if movingPawn || m.Kind.isCapture() {
+		pos.halfmoveClock = 0
+	} else {
+		pos.halfmoveClock++
+	}
+	if side == Black {
+		pos.fullmoveNumber++
+	}
+	pos.sideToMove = enemy
+	return pos
+}
+
+func promotionRune(p rune, c Color) rune {
+	if c == White {
+		switch p {
+		case 'n':
+			return 'N'
+		case 'b':
+			return 'B'
+		case 'r':
+			return 'R'
+		default:
+			return 'Q'
+		}
+	}
+	return p
+}
+
+// movePiece relocates whatever color-c piece sits on from to to, leaving
+// every other bitboard untouched.
+func movePiece(pos *Position, c Color, from, to int) {
+	fromBit := uint64(1) << uint(from)
+	toBit := uint64(1) << uint(to)
+	for _, bb := range piecePlanes(pos, c) {
+		if *bb&fromBit != 0 {
+			*bb &^= fromBit
+			*bb |= toBit
+			return
+		}
+	}
+}
+
+// clearSquare removes whatever color-c piece sits on sq, if any.
+func clearSquare(pos *Position, c Color, sq int) {
+	bit := uint64(1) << uint(sq)
+	for _, bb := range piecePlanes(pos, c) {
+		*bb &^= bit
+	}
+}
+
+func piecePlanes(pos *Position, c Color) []*uint64 {
+	if c == White {
+		return []*uint64{&pos.whitePawns, &pos.whiteKnights, &pos.whiteBishops, &pos.whiteRooks, &pos.whiteQueens, &pos.whiteKing}
+	}
+	return []*uint64{&pos.blackPawns, &pos.blackKnights, &pos.blackBishops, &pos.blackRooks, &pos.blackQueens, &pos.blackKing}
+}
+
+func updateCastlingRights(pos *Position, sq int) {
+	switch sq {
+	case E1:
+		pos.castleWK, pos.castleWQ = false, false
+	case E8:
+		pos.castleBK, pos.castleBQ = false, false
+	case H1:
+		pos.castleWK = false
+	case A1:
+		pos.castleWQ = false
+	case H8:
+		pos.castleBK = false
+	case A8:
+		pos.castleBQ = false
+	}
+}
+
+const (
+	B1 = 1
+	C1 = 2
+	D1 = 3
+	E1 = 4
+	F1 = 5
+	G1 = 6
+	B8 = 57
+	C8 = 58
+	D8 = 59
+	E8 = 60
+	F8 = 61
+	G8 = 62
+)
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+// perftTestDepth caps how deep the table-driven test below goes (as
+// opposed to the "perft" CLI mode, which can run to whatever depth the
+// known counts in perftSuite cover) so go test stays fast.
+const perftTestDepth = 4
+
+func TestPerft(t *testing.T) {
+	for _, tc := range perftSuite {
+		t.Run(tc.name, func(t *testing.T) {
+			pos, err := parseFEN(tc.fen)
+			if err != nil {
+				t.Fatalf("parsing FEN %q: %v", tc.fen, err)
+			}
+			for depth := 1; depth <= perftTestDepth && depth <= len(tc.counts); depth++ {
+				want := tc.counts[depth-1]
+				got := Perft(depth, &pos)
+				if got != want {
+					t.Errorf("Perft(%d, %s): got %d, want %d", depth, tc.name, got, want)
+				}
+			}
+		})
+	}
+}
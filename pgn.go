@@ -0,0 +1,358 @@
+package main
+
+// PGN ingestion: parsing tag pairs and SAN movetext, then replaying a
+// game through the legal move generator to get a per-ply check/mate
+// trail. This lives alongside the rest of the analyzer rather than in a
+// separate package, but is kept to its own file as a distinct concern.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// PGNGame is one parsed game: its seven-tag-roster (and any other) tags,
+// the mainline SAN moves in order, and the game result if present.
+type PGNGame struct {
+	Tags     map[string]string
+	SANMoves []string
+	Result   string
+}
+
+var pgnTagRe = regexp.MustCompile(`^\[(\w+)\s+"(.*)"\]$`)
+
+// ParsePGN reads one or more games from r. It understands tag pairs,
+// movetext, comments ({...}), NAGs ($1), and variations ((...), which may
+// nest) - variations are discarded, so replay follows the mainline only.
+func ParsePGN(r io.Reader) ([]*PGNGame, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var games []*PGNGame
+	var cur *PGNGame
+	var moveBuf strings.Builder
+
+	finish := func() {
+		if cur == nil {
+			return
+		}
+		moves, result := extractSANMoves(moveBuf.String())
+		cur.SANMoves = moves
+		if result != "" {
+			cur.Result = result
+		}
+		games = append(games, cur)
+		cur = nil
+		moveBuf.Reset()
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if m := pgnTagRe.FindStringSubmatch(line); m != nil {
+			if cur != nil && moveBuf.Len() > 0 {
+				finish() // a tag after movetext means a new game started
+			}
+			if cur == nil {
+				cur = &PGNGame{Tags: map[string]string{}}
+			}
+			cur.Tags[m[1]] = m[2]
+			continue
+		}
+		if cur == nil {
+			cur = &PGNGame{Tags: map[string]string{}}
+		}
+		moveBuf.WriteString(line)
+		moveBuf.WriteByte(' ')
+	}
+	finish()
+	return games, scanner.Err()
+}
+
+var pgnResultTokens = map[string]bool{"1-0": true, "0-1": true, "1/2-1/2": true, "*": true}
+
+// extractSANMoves strips comments, variations, NAGs and move numbers out
+// of raw movetext, returning the ordered mainline SAN tokens and the
+// trailing result token if one was present.
+func extractSANMoves(movetext string) (moves []string, result string) {
+	var sb strings.Builder
+	depth := 0
+	inComment := false
+	for i := 0; i < len(movetext); i++ {
+		c := movetext[i]
+		switch {
+		case inComment:
+			if c == '}' {
+				inComment = false
+			}
+		case c == '{':
+			inComment = true
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+			// inside a variation, discard
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	for _, tok := range strings.Fields(sb.String()) {
+		if pgnResultTokens[tok] {
+			result = tok
+			continue
+		}
+		if strings.HasPrefix(tok, "$") {
+			continue // NAG
+		}
+		tok = stripMoveNumber(tok)
+		if tok == "" {
+			continue
+		}
+		moves = append(moves, tok)
+	}
+	return moves, result
+}
+
+// stripMoveNumber removes a leading "12." or "12..." move-number prefix,
+// which PGN writers may glue directly onto the following move ("12.e4").
+func stripMoveNumber(tok string) string {
+	i := 0
+	for i < len(tok) && tok[i] >= '0' && tok[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return tok
+	}
+	j := i
+	for j < len(tok) && tok[j] == '.' {
+		j++
+	}
+	if j == i {
+		return tok // digits not followed by '.', not a move number
+	}
+	return tok[j:]
+}
+
+// ---------------------- SAN parsing ------------------------
+
+var sanRe = regexp.MustCompile(`^([NBRQK]?)([a-h]?)([1-8]?)(x?)([a-h][1-8])(?:=([NBRQ]))?$`)
+
+// parseSAN resolves a SAN token to the single legal move it identifies,
+// disambiguating against pos's legal move list rather than trusting the
+// token's own file/rank hints in isolation.
+func parseSAN(pos *Position, token string) (Move, error) {
+	side := pos.SideToMove()
+	san := strings.TrimRight(token, "+#")
+
+	if san == "O-O" || san == "0-0" {
+		return findCastling(pos, side, CastleKingside)
+	}
+	if san == "O-O-O" || san == "0-0-0" {
+		return findCastling(pos, side, CastleQueenside)
+	}
+
+	m := sanRe.FindStringSubmatch(san)
+	if m == nil {
+		return Move{}, fmt.Errorf("unrecognized SAN move %q", token)
+	}
+	pieceLetter, fileHint, rankHint, capture, destStr, promo := m[1], m[2], m[3], m[4], m[5], m[6]
+
+	wantPiece := Pawn
+	if pieceLetter != "" {
+		wantPiece = pieceFromLetter(pieceLetter[0])
+	}
+	dest, ok := squareFromAlgebraic(destStr)
+	if !ok {
+		return Move{}, fmt.Errorf("bad destination square in %q", token)
+	}
+
+	candidates := LegalMoves(pos, side)
+	var matches []Move
+	for _, cm := range candidates {
+		if cm.To != dest {
+			continue
+		}
+		if pieceTypeAt(pos, cm.From) != wantPiece {
+			continue
+		}
+		if fileHint != "" && cm.From%8 != int(fileHint[0]-'a') {
+			continue
+		}
+		if rankHint != "" && cm.From/8 != int(rankHint[0]-'1') {
+			continue
+		}
+		if promo != "" {
+			p, isPromo := cm.Kind.promotionPiece()
+			if !isPromo || strings.ToUpper(string(p)) != promo {
+				continue
+			}
+		} else if cm.Kind.isPromotion() {
+			continue
+		}
+		matches = append(matches, cm)
+	}
+
+	if len(matches) > 1 && capture == "x" {
+		filtered := matches[:0]
+		for _, cm := range matches {
+			if cm.Kind.isCapture() {
+				filtered = append(filtered, cm)
+			}
+		}
+		matches = filtered
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return Move{}, fmt.Errorf("no legal move matches SAN %q in position %s", token, pos.FEN())
+	default:
+		return Move{}, fmt.Errorf("ambiguous SAN %q in position %s", token, pos.FEN())
+	}
+}
+
+func findCastling(pos *Position, side Color, kind MoveKind) (Move, error) {
+	for _, m := range LegalMoves(pos, side) {
+		if m.Kind == kind {
+			return m, nil
+		}
+	}
+	return Move{}, fmt.Errorf("castling is not legal in position %s", pos.FEN())
+}
+
+func pieceFromLetter(ch byte) PieceType {
+	switch ch {
+	case 'N':
+		return Knight
+	case 'B':
+		return Bishop
+	case 'R':
+		return Rook
+	case 'Q':
+		return Queen
+	case 'K':
+		return King
+	default:
+		return Pawn
+	}
+}
+
+func pieceTypeAt(pos *Position, sq int) PieceType {
+	bit := uint64(1) << uint(sq)
+	switch {
+	case (pos.whitePawns|pos.blackPawns)&bit != 0:
+		return Pawn
+	case (pos.whiteKnights|pos.blackKnights)&bit != 0:
+		return Knight
+	case (pos.whiteBishops|pos.blackBishops)&bit != 0:
+		return Bishop
+	case (pos.whiteRooks|pos.blackRooks)&bit != 0:
+		return Rook
+	case (pos.whiteQueens|pos.blackQueens)&bit != 0:
+		return Queen
+	case (pos.whiteKing|pos.blackKing)&bit != 0:
+		return King
+	default:
+		return NoPiece
+	}
+}
+
+// ---------------------- Replay ------------------------
+
+// PlyRecord is the state after playing one ply of a game, together with
+// the check/mate status the request asks callers to be able to observe.
+type PlyRecord struct {
+	Position     Position
+	Move         Move
+	WhiteInCheck bool
+	BlackInCheck bool
+	IsMate       bool
+}
+
+// ReplayGame plays game's mainline through the legal move generator,
+// returning one PlyRecord per ply. SAN check/mate suffixes ("+", "#") are
+// validated against the computed check state; a mismatch is an error
+// rather than being silently accepted.
+func ReplayGame(game *PGNGame) ([]PlyRecord, error) {
+	fen := startFEN
+	if f := game.Tags["FEN"]; f != "" {
+		fen = f
+	}
+	pos, err := parseFEN(fen)
+	if err != nil {
+		return nil, fmt.Errorf("parsing FEN tag: %w", err)
+	}
+
+	records := make([]PlyRecord, 0, len(game.SANMoves))
+	for i, token := range game.SANMoves {
+		mv, err := parseSAN(&pos, token)
+		if err != nil {
+			return records, fmt.Errorf("ply %d (%q): %w", i+1, token, err)
+		}
+		next := ApplyMove(pos, mv)
+
+		whiteInCheck := next.Checkers(White) != 0
+		blackInCheck := next.Checkers(Black) != 0
+		sideInCheck := whiteInCheck
+		if next.SideToMove() == Black {
+			sideInCheck = blackInCheck
+		}
+		isMate := sideInCheck && len(LegalMoves(&next, next.SideToMove())) == 0
+
+		if strings.HasSuffix(token, "#") && !isMate {
+			return records, fmt.Errorf("ply %d (%q): annotated as mate but position is not checkmate", i+1, token)
+		}
+		if strings.HasSuffix(token, "+") && !sideInCheck {
+			return records, fmt.Errorf("ply %d (%q): annotated as check but position is not in check", i+1, token)
+		}
+
+		records = append(records, PlyRecord{
+			Position:     next,
+			Move:         mv,
+			WhiteInCheck: whiteInCheck,
+			BlackInCheck: blackInCheck,
+			IsMate:       isMate,
+		})
+		pos = next
+	}
+	return records, nil
+}
+
+// runPGNAnalysis implements the "pgn <file>" CLI mode: for every ply of
+// every game in the file, print the resulting FEN and check status.
+func runPGNAnalysis(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pgn:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	games, err := ParsePGN(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pgn: parse error:", err)
+		os.Exit(1)
+	}
+
+	for gi, game := range games {
+		fmt.Printf("Game %d: %s vs %s\n", gi+1, game.Tags["White"], game.Tags["Black"])
+		records, err := ReplayGame(game)
+		for i, rec := range records {
+			fmt.Printf("  ply %-3d %-8s FEN: %s  whiteInCheck=%v blackInCheck=%v mate=%v\n",
+				i+1, rec.Move, rec.Position.FEN(), rec.WhiteInCheck, rec.BlackInCheck, rec.IsMate)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "  replay error:", err)
+		}
+	}
+}
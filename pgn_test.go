@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// pgnCase feeds a whole PGN text through ParsePGN and ReplayGame and checks
+// the resulting move sequence (in UCI long algebraic, via Move.String())
+// or, for the error cases, a substring of the returned error.
+type pgnCase struct {
+	name      string
+	pgn       string
+	wantMoves []string
+	wantErr   string // substring to look for; empty means expect success
+}
+
+var pgnCases = []pgnCase{
+	{
+		name: "disambiguated knight move",
+		pgn: `[FEN "4k3/8/8/8/8/5N2/8/1N2K3 w - - 0 1"]
+
+1. Nbd2 *
+`,
+		wantMoves: []string{"b1d2"},
+	},
+	{
+		name: "pawn capture",
+		pgn: `[FEN "4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1"]
+
+1. exd5 *
+`,
+		wantMoves: []string{"e4d5"},
+	},
+	{
+		name: "promotion",
+		pgn: `[FEN "4k3/P7/8/8/8/8/8/4K3 w - - 0 1"]
+
+1. a8=Q *
+`,
+		wantMoves: []string{"a7a8q"},
+	},
+	{
+		name: "kingside castling",
+		pgn: `[FEN "4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1"]
+
+1. O-O *
+`,
+		wantMoves: []string{"e1g1"},
+	},
+	{
+		name: "comments, variations and NAGs are stripped",
+		pgn: `[Event "Test"]
+
+1. e4 {a good move} (1. d4 d5) $1 e5 2. Nf3 Nc6 *
+`,
+		wantMoves: []string{"e2e4", "e7e5", "g1f3", "b8c6"},
+	},
+	{
+		name: "check suffix that doesn't check is an error",
+		pgn: `[FEN "4k3/8/8/8/8/5N2/8/1N2K3 w - - 0 1"]
+
+1. Nbd2+ *
+`,
+		wantErr: "annotated as check but position is not in check",
+	},
+	{
+		name: "mate suffix on a non-mating move is an error",
+		pgn: `[Event "Test"]
+
+1. e4# *
+`,
+		wantErr: "annotated as mate but position is not checkmate",
+	},
+}
+
+func TestReplayGame(t *testing.T) {
+	for _, tc := range pgnCases {
+		t.Run(tc.name, func(t *testing.T) {
+			games, err := ParsePGN(strings.NewReader(tc.pgn))
+			if err != nil {
+				t.Fatalf("ParsePGN: %v", err)
+			}
+			if len(games) != 1 {
+				t.Fatalf("ParsePGN: got %d games, want 1", len(games))
+			}
+
+			records, err := ReplayGame(games[0])
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("ReplayGame: got no error, want one containing %q", tc.wantErr)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ReplayGame error %q does not contain %q", err.Error(), tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReplayGame: %v", err)
+			}
+
+			if len(records) != len(tc.wantMoves) {
+				t.Fatalf("ReplayGame: got %d plies, want %d", len(records), len(tc.wantMoves))
+			}
+			for i, want := range tc.wantMoves {
+				if got := records[i].Move.String(); got != want {
+					t.Errorf("ply %d: got move %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !magic
+
+package main
+
+// Default build: sliding attacks go through Hyperbola Quintessence. Build
+// with -tags magic to route the same call sites through the magic
+// bitboard tables in magic.go instead.
+var (
+	rookAttacks   = rookAttacksHQ
+	bishopAttacks = bishopAttacksHQ
+	queenAttacks  = queenAttacksHQ
+)
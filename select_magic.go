@@ -0,0 +1,11 @@
+//go:build magic
+
+package main
+
+// Built with -tags magic: sliding attacks go through the precomputed
+// magic bitboard tables instead of Hyperbola Quintessence.
+var (
+	rookAttacks   = rookAttacksMagic
+	bishopAttacks = bishopAttacksMagic
+	queenAttacks  = queenAttacksMagic
+)
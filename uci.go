@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+)
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// uciEngine holds the state a UCI session accumulates between commands:
+// the GUI sends "position" once and then expects "go" to search whatever
+// was last set up.
+type uciEngine struct {
+	pos Position
+}
+
+// runUCI drives a UCI session over in/out until "quit" or EOF. Without a
+// search, "go" just plays a random legal move - enough for a GUI like
+// CuteChess to drive the analyzer as an opponent.
+func runUCI(in io.Reader, out io.Writer) {
+	e := &uciEngine{}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "uci":
+			fmt.Fprintln(out, "id name ChessCheckAnalyzer")
+			fmt.Fprintln(out, "id author Arceus-7")
+			fmt.Fprintln(out, "uciok")
+		case "isready":
+			fmt.Fprintln(out, "readyok")
+		case "ucinewgame":
+			e.pos = Position{}
+		case "position":
+			e.handlePosition(fields[1:])
+		case "go":
+			e.handleGo(out)
+		case "stop":
+			// No background search is running, so nothing to stop.
+		case "quit":
+			return
+		}
+	}
+}
+
+func (e *uciEngine) handlePosition(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	i := 0
+	var fen string
+	switch args[0] {
+	case "startpos":
+		fen = startFEN
+		i = 1
+	case "fen":
+		// FEN is six space-separated fields.
+		end := i + 1
+		for end < len(args) && end-i-1 < 6 && args[end] != "moves" {
+			end++
+		}
+		fen = strings.Join(args[1:end], " ")
+		i = end
+	default:
+		return
+	}
+
+	pos, err := parseFEN(fen)
+	if err != nil {
+		return
+	}
+	e.pos = pos
+
+	if i < len(args) && args[i] == "moves" {
+		for _, mv := range args[i+1:] {
+			m, ok := findMove(&e.pos, mv)
+			if !ok {
+				return
+			}
+			e.pos = ApplyMove(e.pos, m)
+		}
+	}
+}
+
+func (e *uciEngine) handleGo(out io.Writer) {
+	moves := LegalMoves(&e.pos, e.pos.SideToMove())
+	if len(moves) == 0 {
+		fmt.Fprintln(out, "bestmove 0000")
+		return
+	}
+	m := moves[rand.Intn(len(moves))]
+	fmt.Fprintf(out, "bestmove %s\n", m)
+}
+
+// findMove looks up the legal move matching a long-algebraic UCI move
+// string ("e2e4", "e7e8q") against the position's legal move list.
+func findMove(pos *Position, uciMove string) (Move, bool) {
+	for _, m := range LegalMoves(pos, pos.SideToMove()) {
+		if m.String() == uciMove {
+			return m, true
+		}
+	}
+	return Move{}, false
+}